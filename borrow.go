@@ -0,0 +1,142 @@
+package secrets
+
+import (
+	"github.com/stouset/go.secrets/memguard"
+)
+
+// borrowedMut is the state value recorded while a BorrowMut is
+// outstanding. It's negative so that it can never be confused with a
+// (necessarily non-negative) reader count.
+const borrowedMut = -1
+
+// A Borrow grants shared, read-only access to a Secret's contents,
+// obtained by calling Secret.Borrow. Multiple Borrows may be
+// outstanding on the same Secret at once; their acquisition coalesces
+// into a single PROT_READ protection change, and the memory is only
+// returned to PROT_NONE once every outstanding Borrow has been
+// released.
+type Borrow struct {
+	secret *secret
+}
+
+// Slice returns a byte slice viewing the Borrow's contents. The slice
+// is only valid until the Borrow is released.
+func (b *Borrow) Slice() []byte { return Secret{b.secret}.Slice() }
+
+// Release relinquishes this Borrow. It panics if called more than
+// once on the same Borrow.
+func (b *Borrow) Release() {
+	if b.secret == nil {
+		panic("secrets: Borrow released more than once")
+	}
+
+	b.secret.unborrow()
+	b.secret = nil
+}
+
+// A BorrowMut grants exclusive, read-write access to a Secret's
+// contents, obtained by calling Secret.BorrowMut. No other Borrow or
+// BorrowMut may be outstanding on the same Secret while a BorrowMut
+// exists.
+type BorrowMut struct {
+	secret *secret
+}
+
+// Slice returns a byte slice viewing the BorrowMut's contents. The
+// slice is only valid until the BorrowMut is released.
+func (b *BorrowMut) Slice() []byte { return Secret{b.secret}.Slice() }
+
+// Release relinquishes this BorrowMut. It panics if called more than
+// once on the same BorrowMut.
+func (b *BorrowMut) Release() {
+	if b.secret == nil {
+		panic("secrets: BorrowMut released more than once")
+	}
+
+	b.secret.unborrowMut()
+	b.secret = nil
+}
+
+// Borrow acquires shared, read-only access to the Secret's contents.
+//
+// It panics if a BorrowMut is already outstanding on this Secret.
+func (s Secret) Borrow() *Borrow {
+	s.secret.borrow()
+	return &Borrow{s.secret}
+}
+
+// BorrowMut acquires exclusive, read-write access to the Secret's
+// contents.
+//
+// It panics if any Borrow or BorrowMut is already outstanding on this
+// Secret.
+func (s Secret) BorrowMut() *BorrowMut {
+	s.secret.borrowMut()
+	return &BorrowMut{s.secret}
+}
+
+// borrow registers a new reader, unlocking the underlying memory for
+// PROT_READ the first time a reader is registered.
+//
+// The mprotect call happens while borrowMu is held, and state isn't
+// advanced until it returns, so another goroutine can never observe
+// an incremented reader count before the page is actually readable.
+func (s *secret) borrow() {
+	s.borrowMu.Lock()
+	defer s.borrowMu.Unlock()
+
+	if s.state == borrowedMut {
+		panic("secrets: Borrow called on a Secret that is already BorrowMut'd")
+	}
+
+	if s.state == 0 {
+		s.unlock(memguard.Read)
+	}
+
+	s.state++
+}
+
+// unborrow releases a reader previously registered with borrow,
+// re-locking the underlying memory once the last reader is released.
+func (s *secret) unborrow() {
+	s.borrowMu.Lock()
+	defer s.borrowMu.Unlock()
+
+	if s.state <= 0 {
+		panic("secrets: Borrow released more times than it was acquired")
+	}
+
+	s.state--
+
+	if s.state == 0 {
+		s.lock()
+	}
+}
+
+// borrowMut registers the single outstanding writer, unlocking the
+// underlying memory for PROT_READ|PROT_WRITE.
+func (s *secret) borrowMut() {
+	s.borrowMu.Lock()
+	defer s.borrowMu.Unlock()
+
+	if s.state != 0 {
+		panic("secrets: BorrowMut called on a Secret that is already Borrow'd or BorrowMut'd")
+	}
+
+	s.unlock(memguard.Read | memguard.Write)
+	s.state = borrowedMut
+}
+
+// unborrowMut releases the writer previously registered with
+// borrowMut, re-locking the underlying memory.
+func (s *secret) unborrowMut() {
+	s.borrowMu.Lock()
+	defer s.borrowMu.Unlock()
+
+	if s.state != borrowedMut {
+		panic("secrets: BorrowMut released more than once")
+	}
+
+	s.state = 0
+	s.lock()
+}
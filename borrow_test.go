@@ -0,0 +1,114 @@
+package secrets
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ExampleSecret_Borrow() {
+	secret, _ := NewSecretFromBytes([]byte("secret!"))
+
+	b1 := secret.Borrow()
+	b2 := secret.Borrow()
+
+	fmt.Printf("%s %s", b1.Slice(), b2.Slice())
+
+	b1.Release()
+	b2.Release()
+
+	secret.Wipe()
+
+	// Output: secret! secret!
+}
+
+func ExampleSecret_BorrowMut() {
+	secret, _ := NewSecret(7)
+
+	b := secret.BorrowMut()
+	copy(b.Slice(), "secret!")
+	b.Release()
+
+	r := secret.Borrow()
+	fmt.Printf("%s", r.Slice())
+	r.Release()
+
+	secret.Wipe()
+
+	// Output: secret!
+}
+
+func TestBorrowMutWhileBorrowed(t *testing.T) {
+	secret, err := NewSecret(8)
+
+	if err != nil {
+		t.Fatalf("NewSecret(8) = _, %v; want nil", err)
+	}
+
+	b := secret.Borrow()
+	defer b.Release()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("BorrowMut() while Borrow'd should have panicked")
+		}
+	}()
+
+	secret.BorrowMut()
+}
+
+func TestBorrowWhileBorrowedMut(t *testing.T) {
+	secret, err := NewSecret(8)
+
+	if err != nil {
+		t.Fatalf("NewSecret(8) = _, %v; want nil", err)
+	}
+
+	b := secret.BorrowMut()
+	defer b.Release()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Borrow() while BorrowMut'd should have panicked")
+		}
+	}()
+
+	secret.Borrow()
+}
+
+func TestBorrowReleasedTwice(t *testing.T) {
+	secret, err := NewSecret(8)
+
+	if err != nil {
+		t.Fatalf("NewSecret(8) = _, %v; want nil", err)
+	}
+
+	b := secret.Borrow()
+	b.Release()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("releasing a Borrow twice should have panicked")
+		}
+	}()
+
+	b.Release()
+}
+
+func TestBorrowMutReleasedTwice(t *testing.T) {
+	secret, err := NewSecret(8)
+
+	if err != nil {
+		t.Fatalf("NewSecret(8) = _, %v; want nil", err)
+	}
+
+	b := secret.BorrowMut()
+	b.Release()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("releasing a BorrowMut twice should have panicked")
+		}
+	}()
+
+	b.Release()
+}
@@ -0,0 +1,147 @@
+//go:build go1.18
+
+package secrets
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// A TypedSecret[T] is a guarded, fixed-size region of memory sized to
+// hold a single value of type T. It's the generic counterpart to
+// Secret: instead of a raw byte slice, Borrow and BorrowMut return a
+// *T pointing directly into the protected page, so callers never need
+// to reinterpret bytes by hand.
+//
+// T must be a plain-old-data type: it, and recursively any arrays or
+// structs it's composed of, may not contain pointers, maps, slices,
+// channels, functions, interfaces, or strings. This is enforced at
+// construction time, so the garbage collector never has a reason to
+// scan into the guarded region; if it did, a stale or swapped-out
+// pointer found there could crash the program or corrupt the heap.
+type TypedSecret[T any] struct {
+	secret *secret
+	size   uintptr
+}
+
+// NewTypedSecret allocates a guarded, zero-initialized TypedSecret
+// capable of holding a T.
+//
+// If T is not plain-old-data, or the underlying memory can't be
+// allocated or protected, an error is returned.
+func NewTypedSecret[T any]() (*TypedSecret[T], error) {
+	var zero T
+
+	if err := checkPOD(reflect.TypeOf(zero)); err != nil {
+		return nil, err
+	}
+
+	sec := &secret{}
+
+	if err := sec.alloc(unsafe.Sizeof(zero)); err != nil {
+		return nil, err
+	}
+
+	return &TypedSecret[T]{sec, unsafe.Sizeof(zero)}, nil
+}
+
+// NewTypedSecret32 allocates a guarded TypedSecret holding a 32-byte
+// array, the size of many symmetric keys (e.g. AES-256, ChaCha20, and
+// Curve25519 scalars).
+func NewTypedSecret32() (*TypedSecret[[32]byte], error) {
+	return NewTypedSecret[[32]byte]()
+}
+
+// NewTypedSecret64 allocates a guarded TypedSecret holding a 64-byte
+// array, the size of many asymmetric keys and MACs (e.g. Ed25519
+// private keys and SHA-512 HMACs).
+func NewTypedSecret64() (*TypedSecret[[64]byte], error) {
+	return NewTypedSecret[[64]byte]()
+}
+
+// Borrow allows the TypedSecret's contents to be read, returning a
+// pointer to the underlying T. Immediately after calling this method,
+// always `defer secret.Release()` to ensure its protection is
+// restored.
+//
+// Borrow shares the same refcounted state as Secret.Borrow: multiple
+// Borrows may be outstanding at once, and it panics if a BorrowMut is
+// already outstanding.
+func (s *TypedSecret[T]) Borrow() *T {
+	s.secret.borrow()
+	return (*T)(s.secret.ptr)
+}
+
+// BorrowMut allows the TypedSecret's contents to be read from and
+// written to, returning a pointer to the underlying T. Immediately
+// after calling this method, always `defer secret.Release()` to
+// ensure its protection is restored.
+//
+// BorrowMut shares the same refcounted state as Secret.BorrowMut: it
+// panics if any Borrow or BorrowMut is already outstanding.
+func (s *TypedSecret[T]) BorrowMut() *T {
+	s.secret.borrowMut()
+	return (*T)(s.secret.ptr)
+}
+
+// Release relinquishes the Borrow or BorrowMut most recently acquired
+// by Borrow or BorrowMut, re-locking the TypedSecret's contents once
+// the last one is released.
+func (s *TypedSecret[T]) Release() {
+	s.secret.unlockLegacy()
+}
+
+// Bytes returns a byte slice viewing the TypedSecret's contents,
+// without copying them, for interop with APIs such as
+// crypto/cipher.Block that operate on raw key bytes. It must only be
+// called while the TypedSecret is borrowed.
+func (s *TypedSecret[T]) Bytes() []byte {
+	sh := reflect.SliceHeader{
+		Data: uintptr(s.secret.ptr),
+		Len:  int(s.size),
+		Cap:  int(s.size),
+	}
+
+	return *(*[]byte)(unsafe.Pointer(&sh))
+}
+
+// Wipe immediately zeroes out and releases the TypedSecret's memory.
+// Any attempt to reuse a TypedSecret after a call to Wipe() will
+// result in undefined behavior.
+func (s *TypedSecret[T]) Wipe() {
+	secrets := Secret{s.secret}
+	secrets.Wipe()
+}
+
+// checkPOD verifies that t is plain-old-data: composed only of
+// fixed-size primitives, arrays, and structs, with no pointers, maps,
+// slices, channels, functions, interfaces, or strings anywhere in its
+// structure.
+func checkPOD(t reflect.Type) error {
+	// reflect.TypeOf returns nil for a nil interface value, which is
+	// what `var zero T` produces when T is itself an interface type
+	// (e.g. TypedSecret[any]); treat that the same as any other
+	// disallowed interface type rather than panicking on t.Kind().
+	if t == nil {
+		return fmt.Errorf("secrets: interface types are not plain-old-data")
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Chan,
+		reflect.Func, reflect.Interface, reflect.String, reflect.UnsafePointer:
+		return fmt.Errorf("secrets: %s is not a plain-old-data type", t)
+
+	case reflect.Array:
+		return checkPOD(t.Elem())
+
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if err := checkPOD(t.Field(i).Type); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
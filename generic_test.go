@@ -0,0 +1,91 @@
+//go:build go1.18
+
+package secrets
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ExampleTypedSecret_Borrow() {
+	secret, err := NewTypedSecret32()
+
+	if err != nil {
+		return
+	}
+
+	key := secret.BorrowMut()
+	key[0] = 0x42
+	secret.Release()
+
+	fmt.Printf("0x%x", secret.Borrow()[0])
+	secret.Release()
+
+	secret.Wipe()
+
+	// Output: 0x42
+}
+
+func TestTypedSecretRejectsPointers(t *testing.T) {
+	type hasPointer struct {
+		key *[32]byte
+	}
+
+	if _, err := NewTypedSecret[hasPointer](); err == nil {
+		t.Error("NewTypedSecret[hasPointer]() = _, nil; want non-nil error")
+	}
+}
+
+func TestTypedSecretRejectsBareInterface(t *testing.T) {
+	if _, err := NewTypedSecret[any](); err == nil {
+		t.Error("NewTypedSecret[any]() = _, nil; want non-nil error")
+	}
+}
+
+func TestTypedSecretBorrowMutWhileBorrowed(t *testing.T) {
+	secret, err := NewTypedSecret32()
+
+	if err != nil {
+		t.Fatalf("NewTypedSecret32() = _, %v; want nil", err)
+	}
+
+	secret.Borrow()
+	defer secret.Release()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("BorrowMut() while Borrow'd should have panicked")
+		}
+	}()
+
+	secret.BorrowMut()
+}
+
+func TestTypedSecretAllowsPlainStructs(t *testing.T) {
+	type keyPair struct {
+		public  [32]byte
+		private [32]byte
+	}
+
+	if _, err := NewTypedSecret[keyPair](); err != nil {
+		t.Errorf("NewTypedSecret[keyPair]() = _, %v; want nil", err)
+	}
+}
+
+func TestTypedSecretBytes(t *testing.T) {
+	secret, err := NewTypedSecret32()
+
+	if err != nil {
+		t.Fatalf("NewTypedSecret32() = _, %v; want nil", err)
+	}
+
+	key := secret.BorrowMut()
+	copy(key[:], []byte("01234567890123456789012345678901"))
+
+	if len(secret.Bytes()) != 32 {
+		t.Errorf("len(secret.Bytes()) = %d; want 32", len(secret.Bytes()))
+	}
+
+	secret.Release()
+	secret.Wipe()
+}
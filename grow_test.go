@@ -0,0 +1,111 @@
+package secrets
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ExampleSecret_Grow() {
+	secret, err := NewSecretFromBytes([]byte("secr"))
+
+	if err != nil {
+		return
+	}
+
+	secret.Grow(7)
+
+	secret.Write()
+	copy(secret.Slice()[4:], "et!")
+	secret.Lock()
+
+	secret.Read()
+	defer secret.Lock()
+
+	fmt.Printf("%s", secret.Slice())
+
+	secret.Wipe()
+
+	// Output: secret!
+}
+
+func ExampleSecret_Append() {
+	secret1, _ := NewSecretFromBytes([]byte("secr"))
+	secret2, _ := NewSecretFromBytes([]byte("et!"))
+
+	secret1.Append(secret2)
+
+	secret1.Read()
+	defer secret1.Lock()
+
+	fmt.Printf("%s", secret1.Slice())
+
+	secret1.Wipe()
+	secret2.Wipe()
+
+	// Output: secret!
+}
+
+func ExampleConcat() {
+	secret1, _ := NewSecretFromBytes([]byte("se"))
+	secret2, _ := NewSecretFromBytes([]byte("cre"))
+	secret3, _ := NewSecretFromBytes([]byte("t!"))
+
+	joined, err := Concat(secret1, secret2, secret3)
+
+	if err != nil {
+		return
+	}
+
+	joined.Read()
+	defer joined.Lock()
+
+	fmt.Printf("%s", joined.Slice())
+
+	secret1.Wipe()
+	secret2.Wipe()
+	secret3.Wipe()
+	joined.Wipe()
+
+	// Output: secret!
+}
+
+func TestSecretGrowPreservesContents(t *testing.T) {
+	secret, err := NewSecretFromBytes([]byte("secret"))
+
+	if err != nil {
+		t.Fatalf("NewSecretFromBytes() = _, %v; want nil", err)
+	}
+
+	if err := secret.Grow(32); err != nil {
+		t.Fatalf("Grow(32) = %v; want nil", err)
+	}
+
+	secret.Read()
+	defer secret.Lock()
+
+	if got := string(secret.Slice()[:6]); got != "secret" {
+		t.Errorf("secret.Slice()[:6] = %q; want %q", got, "secret")
+	}
+
+	for i, b := range secret.Slice()[6:] {
+		if b != 0 {
+			t.Errorf("secret.Slice()[6+%d] = %d; want 0", i, b)
+		}
+	}
+}
+
+func TestSecretGrowNoOpWhenSmaller(t *testing.T) {
+	secret, err := NewSecret(32)
+
+	if err != nil {
+		t.Fatalf("NewSecret(32) = _, %v; want nil", err)
+	}
+
+	if err := secret.Grow(16); err != nil {
+		t.Fatalf("Grow(16) = %v; want nil", err)
+	}
+
+	if secret.Len() != 32 {
+		t.Errorf("secret.Len() = %d; want 32", secret.Len())
+	}
+}
@@ -0,0 +1,99 @@
+//go:build cgo && !windows
+
+package memguard
+
+// #cgo pkg-config: libsodium
+//
+// #include <sys/mman.h>
+//
+// #include <sodium/core.h>
+// #include <sodium/utils.h>
+//
+// #define _MAP_FAILED (intptr_t)MAP_FAILED
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+)
+
+func init() {
+	if int(C.sodium_init()) == -1 {
+		panic("memguard: libsodium couldn't be initialized")
+	}
+}
+
+// cgoAllocator is the default Allocator on Unix systems built with
+// cgo: it allocates with mmap, protects with mprotect, and locks and
+// compares with libsodium, which additionally guards against the
+// optimizer eliding security-sensitive memory operations.
+type cgoAllocator struct{}
+
+// New returns an Allocator backed by libsodium and mmap/mprotect.
+func New() Allocator { return cgoAllocator{} }
+
+func (cgoAllocator) Alloc(size uintptr) (unsafe.Pointer, error) {
+	ptr, err := C.mmap(nil, C.size_t(size), C.PROT_NONE, C.MAP_ANON|C.MAP_PRIVATE, -1, 0)
+
+	if int(uintptr(ptr)) == C._MAP_FAILED {
+		return nil, err
+	}
+
+	return ptr, nil
+}
+
+func (cgoAllocator) Free(ptr unsafe.Pointer, size uintptr) error {
+	if ret := C.munmap(ptr, C.size_t(size)); ret != 0 {
+		return errors.New("memguard: munmap failed")
+	}
+
+	return nil
+}
+
+func (cgoAllocator) Protect(ptr unsafe.Pointer, size uintptr, prot Prot) error {
+	if ret, err := C.mprotect(ptr, C.size_t(size), cProt(prot)); ret != 0 {
+		return err
+	}
+
+	return nil
+}
+
+func (cgoAllocator) Lock(ptr unsafe.Pointer, size uintptr) error {
+	if ret, err := C.sodium_mlock(ptr, C.size_t(size)); ret != 0 {
+		return err
+	}
+
+	return nil
+}
+
+func (cgoAllocator) Unlock(ptr unsafe.Pointer, size uintptr) error {
+	if ret, err := C.sodium_munlock(ptr, C.size_t(size)); ret != 0 {
+		return err
+	}
+
+	return nil
+}
+
+func (cgoAllocator) ConstantTimeCompare(a, b unsafe.Pointer, size uintptr) bool {
+	return C.sodium_memcmp(a, b, C.size_t(size)) == 0
+}
+
+func (cgoAllocator) Zero(ptr unsafe.Pointer, size uintptr) {
+	C.sodium_memzero(ptr, C.size_t(size))
+}
+
+// cProt translates a Prot into the mmap/mprotect PROT_* flags it
+// represents.
+func cProt(prot Prot) C.int {
+	var flags C.int
+
+	if prot&Read != 0 {
+		flags |= C.PROT_READ
+	}
+
+	if prot&Write != 0 {
+		flags |= C.PROT_WRITE
+	}
+
+	return flags
+}
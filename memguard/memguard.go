@@ -0,0 +1,64 @@
+// Package memguard provides the low-level primitives that package
+// secrets builds guarded memory regions on top of: allocating
+// page-aligned anonymous memory, changing its access protection,
+// locking it against being paged to swap, comparing it in constant
+// time, and zeroing it.
+//
+// Exactly one Allocator implementation is compiled into a given
+// binary, selected by build tags: a libsodium- and mmap-backed
+// implementation for cgo builds on Unix, a pure-Go implementation
+// built on golang.org/x/sys/unix for cgo-free Unix builds, and a
+// VirtualAlloc-backed implementation for Windows. Call New to obtain
+// whichever implementation was compiled in.
+package memguard
+
+import "unsafe"
+
+// Prot describes the access permitted to a region of guarded memory.
+// It's a bitmask of Read and Write; the zero value, NoAccess, denies
+// both.
+type Prot int
+
+const (
+	NoAccess Prot = 0
+	Read     Prot = 1 << 0
+	Write    Prot = 1 << 1
+)
+
+// An Allocator provides the primitive operations that package secrets
+// needs in order to build guarded memory regions. Implementations
+// must allocate memory page-aligned, since callers rely on being able
+// to carve off individual pages within an allocation to act as
+// guards.
+type Allocator interface {
+	// Alloc allocates size bytes of page-aligned, anonymous
+	// memory with no access permissions (as if Protect had been
+	// called with NoAccess). The returned memory is not locked
+	// against being paged to swap.
+	Alloc(size uintptr) (unsafe.Pointer, error)
+
+	// Free releases memory previously returned by Alloc back to
+	// the operating system. It does not zero or unlock the
+	// memory first; callers that need those guarantees must call
+	// Zero and Unlock themselves before calling Free.
+	Free(ptr unsafe.Pointer, size uintptr) error
+
+	// Protect changes the access permissions of a previously
+	// allocated region.
+	Protect(ptr unsafe.Pointer, size uintptr, prot Prot) error
+
+	// Lock prevents a region of memory from being paged to swap.
+	Lock(ptr unsafe.Pointer, size uintptr) error
+
+	// Unlock reverses a previous call to Lock.
+	Unlock(ptr unsafe.Pointer, size uintptr) error
+
+	// ConstantTimeCompare reports whether the size bytes at a and
+	// at b are equal, in time independent of their contents.
+	ConstantTimeCompare(a, b unsafe.Pointer, size uintptr) bool
+
+	// Zero overwrites the size bytes at ptr with zeroes. Callers
+	// rely on this not being optimized away even though ptr is
+	// never read again afterwards.
+	Zero(ptr unsafe.Pointer, size uintptr)
+}
@@ -0,0 +1,90 @@
+//go:build !cgo && unix
+
+package memguard
+
+import (
+	"crypto/subtle"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// unixAllocator is the cgo-free Allocator used on Unix systems built
+// without cgo. It allocates, protects, and locks memory directly via
+// golang.org/x/sys/unix, and implements the same constant-time
+// comparison and non-elidable zeroing guarantees that the cgo
+// allocator gets from libsodium.
+type unixAllocator struct{}
+
+// New returns a cgo-free Allocator backed by mmap, mprotect, and
+// mlock.
+func New() Allocator { return unixAllocator{} }
+
+func (unixAllocator) Alloc(size uintptr) (unsafe.Pointer, error) {
+	b, err := unix.Mmap(-1, 0, int(size), unix.PROT_NONE, unix.MAP_ANON|unix.MAP_PRIVATE)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return unsafe.Pointer(&b[0]), nil
+}
+
+func (unixAllocator) Free(ptr unsafe.Pointer, size uintptr) error {
+	return unix.Munmap(sliceOf(ptr, size))
+}
+
+func (unixAllocator) Protect(ptr unsafe.Pointer, size uintptr, prot Prot) error {
+	return unix.Mprotect(sliceOf(ptr, size), unixProt(prot))
+}
+
+func (unixAllocator) Lock(ptr unsafe.Pointer, size uintptr) error {
+	return unix.Mlock(sliceOf(ptr, size))
+}
+
+func (unixAllocator) Unlock(ptr unsafe.Pointer, size uintptr) error {
+	return unix.Munlock(sliceOf(ptr, size))
+}
+
+func (unixAllocator) ConstantTimeCompare(a, b unsafe.Pointer, size uintptr) bool {
+	return subtle.ConstantTimeCompare(sliceOf(a, size), sliceOf(b, size)) == 1
+}
+
+// Zero overwrites size bytes at ptr with zeroes. It's marked
+// noinline, and touches ptr again via runtime.KeepAlive after the
+// loop, so the compiler can't conclude the writes are dead and elide
+// them even though ptr is otherwise never read again.
+//
+//go:noinline
+func (unixAllocator) Zero(ptr unsafe.Pointer, size uintptr) {
+	b := sliceOf(ptr, size)
+
+	for i := range b {
+		b[i] = 0
+	}
+
+	runtime.KeepAlive(ptr)
+}
+
+// unixProt translates a Prot into the unix.PROT_* flags it
+// represents.
+func unixProt(prot Prot) int {
+	var flags int
+
+	if prot&Read != 0 {
+		flags |= unix.PROT_READ
+	}
+
+	if prot&Write != 0 {
+		flags |= unix.PROT_WRITE
+	}
+
+	return flags
+}
+
+// sliceOf reinterprets the size bytes at ptr as a byte slice, for
+// passing to APIs that operate on slices rather than raw pointers.
+func sliceOf(ptr unsafe.Pointer, size uintptr) []byte {
+	return unsafe.Slice((*byte)(ptr), size)
+}
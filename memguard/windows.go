@@ -0,0 +1,91 @@
+//go:build windows
+
+package memguard
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// windowsAllocator is the Allocator used on Windows. It allocates and
+// protects memory via VirtualAlloc/VirtualProtect, and locks it
+// against the page file via VirtualLock.
+type windowsAllocator struct{}
+
+// New returns an Allocator backed by VirtualAlloc, VirtualProtect,
+// and VirtualLock.
+func New() Allocator { return windowsAllocator{} }
+
+func (windowsAllocator) Alloc(size uintptr) (unsafe.Pointer, error) {
+	addr, err := windows.VirtualAlloc(0, size, windows.MEM_COMMIT|windows.MEM_RESERVE, windows.PAGE_NOACCESS)
+
+	if err != nil {
+		return nil, fmt.Errorf("memguard: VirtualAlloc: %w", err)
+	}
+
+	return unsafe.Pointer(addr), nil
+}
+
+func (windowsAllocator) Free(ptr unsafe.Pointer, size uintptr) error {
+	return windows.VirtualFree(uintptr(ptr), 0, windows.MEM_RELEASE)
+}
+
+func (windowsAllocator) Protect(ptr unsafe.Pointer, size uintptr, prot Prot) error {
+	var old uint32
+
+	return windows.VirtualProtect(uintptr(ptr), size, windowsProt(prot), &old)
+}
+
+func (windowsAllocator) Lock(ptr unsafe.Pointer, size uintptr) error {
+	return windows.VirtualLock(uintptr(ptr), size)
+}
+
+func (windowsAllocator) Unlock(ptr unsafe.Pointer, size uintptr) error {
+	return windows.VirtualUnlock(uintptr(ptr), size)
+}
+
+func (windowsAllocator) ConstantTimeCompare(a, b unsafe.Pointer, size uintptr) bool {
+	return subtle.ConstantTimeCompare(sliceOf(a, size), sliceOf(b, size)) == 1
+}
+
+// Zero overwrites size bytes at ptr with zeroes. It's marked
+// noinline, and touches ptr again via runtime.KeepAlive after the
+// loop, so the compiler can't conclude the writes are dead and elide
+// them even though ptr is otherwise never read again.
+//
+//go:noinline
+func (windowsAllocator) Zero(ptr unsafe.Pointer, size uintptr) {
+	b := sliceOf(ptr, size)
+
+	for i := range b {
+		b[i] = 0
+	}
+
+	runtime.KeepAlive(ptr)
+}
+
+// windowsProt translates a Prot into the closest PAGE_* constant it
+// represents. Windows has no write-only page protection, so Write
+// alone is promoted to PAGE_READWRITE.
+func windowsProt(prot Prot) uint32 {
+	switch {
+	case prot&Read != 0 && prot&Write != 0:
+		return windows.PAGE_READWRITE
+	case prot&Write != 0:
+		return windows.PAGE_READWRITE
+	case prot&Read != 0:
+		return windows.PAGE_READONLY
+	default:
+		return windows.PAGE_NOACCESS
+	}
+}
+
+// sliceOf reinterprets the size bytes at ptr as a byte slice, for
+// passing to APIs that operate on slices rather than raw pointers.
+func sliceOf(ptr unsafe.Pointer, size uintptr) []byte {
+	return unsafe.Slice((*byte)(ptr), size)
+}
@@ -14,6 +14,11 @@
 // protections cannot be maintained during the lifespan of an existing
 // Secret, the library will panic.
 //
+// The underlying memory operations are provided by the memguard
+// subpackage, which selects an appropriate backend for the host at
+// build time; this package's API is unaffected by which backend is
+// in use.
+//
 // The use of this package should be limited to storing cryptographic
 // secrets. In order to provide the promised protections, allocations
 // are significantly larger than the amount of memory requested and
@@ -42,45 +47,34 @@
 //
 package secrets
 
-// #cgo pkg-config: libsodium
-//
-// #include <string.h>
-// #include <sys/mman.h>
-// #include <unistd.h>
-//
-// #include <sodium/core.h>
-// #include <sodium/randombytes.h>
-// #include <sodium/utils.h>
-//
-// #define _MAP_FAILED (intptr_t)MAP_FAILED
-import "C"
-
 import (
+	"crypto/rand"
+	"os"
 	"reflect"
 	"runtime"
+	"sync"
 	"unsafe"
+
+	"github.com/stouset/go.secrets/memguard"
 )
 
 var (
+	// the allocator backing every Secret's guarded memory
+	allocator = memguard.New()
+
 	// the size of a page of memory
-	pageSize = C.size_t(C.getpagesize())
+	pageSize = uintptr(os.Getpagesize())
 
-	// the canary will be filled during init()
-	canarySize = C.size_t(128)
-	canary     = C.malloc(canarySize)
+	// the canary is filled with random bytes during init()
+	canarySize = uintptr(128)
+	canary     = make([]byte, canarySize)
 )
 
 func init() {
-	if canary == nil {
-		panic("secrets: couldn't allocate memory for a canary")
-	}
-
-	if int(C.sodium_init()) == -1 {
-		panic("secrets: libsodium couldn't be initialized")
-	}
-
 	// give the canary a cryptographically random default value
-	C.randombytes_buf(canary, canarySize)
+	if _, err := rand.Read(canary); err != nil {
+		panic("secrets: couldn't generate a canary: " + err.Error())
+	}
 }
 
 // A Secret contains a protected cryptographic secret. The contents of
@@ -110,7 +104,7 @@ func NewSecret(
 		return &sec, nil
 	}
 
-	if err = sec.secret.alloc(C.size_t(len)); err != nil {
+	if err = sec.secret.alloc(uintptr(len)); err != nil {
 		return nil, err
 	}
 
@@ -145,8 +139,8 @@ func NewSecretFromBytes(
 	secret.Write()
 	defer secret.Lock()
 
-	C.memcpy(secret.Pointer(), dataPtr, dataSize)
-	C.sodium_memzero(dataPtr, dataSize)
+	copy(secret.Slice(), data)
+	allocator.Zero(dataPtr, dataSize)
 
 	return secret, nil
 }
@@ -154,26 +148,46 @@ func NewSecretFromBytes(
 // Returns the length of the Secret in bytes.
 func (s Secret) Len() int { return int(s.Size()) }
 
-// Returns the C size_t length of the Secret in bytes
-func (s Secret) Size() C.size_t { return s.secret.size }
+// Returns the length of the Secret in bytes, as a uintptr suitable
+// for passing to the memguard package's low-level primitives.
+func (s Secret) Size() uintptr { return s.secret.size }
 
 // Locks the Secret, preventing any access to its contents.
-func (s Secret) Lock() { s.secret.lock() }
+//
+// Lock, Read, Write, and ReadWrite are a legacy API kept for backwards
+// compatibility with code written before Borrow and BorrowMut
+// existed. They're implemented in terms of the same refcounted
+// protection state, so a Read coalesces with an outstanding Borrow
+// rather than yanking the page back to PROT_NONE underneath it, and a
+// Write or ReadWrite panics just like BorrowMut if a Borrow or
+// BorrowMut is already outstanding. New code should prefer Borrow and
+// BorrowMut, which also detect a handle being released more than
+// once.
+func (s Secret) Lock() { s.secret.unlockLegacy() }
 
 // Allows the Secret's contents to be read. Immediately after calling
 // this method, always `defer secret.Lock()` to ensure its protection
 // is restored.
-func (s Secret) Read() { s.secret.unlock(C.PROT_READ) }
+//
+// See the note on Lock about this legacy method's relationship to
+// Borrow and BorrowMut.
+func (s Secret) Read() { s.secret.borrow() }
 
 // Allows the Secret's contents to be written to. Immediately after
 // calling this method, always `defer secret.Lock()` to ensure its
 // protection is restored.
-func (s Secret) Write() { s.secret.unlock(C.PROT_WRITE) }
+//
+// See the note on Lock about this legacy method's relationship to
+// Borrow and BorrowMut.
+func (s Secret) Write() { s.secret.borrowMut() }
 
 // Allows the Secret's contents to be read from and written
 // to. Immediately after calling this method, always `defer
 // secret.Lock()` to ensure its protection is restored.
-func (s Secret) ReadWrite() { s.secret.unlock(C.PROT_READ | C.PROT_WRITE) }
+//
+// See the note on Lock about this legacy method's relationship to
+// Borrow and BorrowMut.
+func (s Secret) ReadWrite() { s.secret.borrowMut() }
 
 // Returns an unsafe.Pointer pointing to the memory contents of the
 // Secret. When accessing memory through this pointer, take care to
@@ -201,29 +215,28 @@ func (s Secret) Slice() []byte {
 	return *(*[]byte)(unsafe.Pointer(&sh))
 }
 
-// Copies a Secret's contents into a new Secret. If either allocating
-// the new Secret or unlocking the existing Secret fails, returns an
-// error.
+// Copies a Secret's contents into a new Secret. If allocating the new
+// Secret fails, returns an error.
+//
+// Copy accesses both Secrets through Borrow/BorrowMut rather than the
+// legacy Lock/Read/Write API, so it nests correctly even if the same
+// Secret already has a Borrow outstanding elsewhere.
 func (s Secret) Copy() (*Secret, error) {
-	copy, err := NewSecret(s.Len())
+	copied, err := NewSecret(s.Len())
 
 	if err != nil {
 		return nil, err
 	}
 
-	copy.Write()
-	defer copy.Lock()
+	w := copied.BorrowMut()
+	defer w.Release()
 
-	s.Read()
-	defer s.Lock()
+	r := s.Borrow()
+	defer r.Release()
 
-	C.memcpy(
-		copy.Pointer(),
-		s.Pointer(),
-		s.Size(),
-	)
+	copy(w.Slice(), r.Slice())
 
-	return copy, nil
+	return copied, nil
 }
 
 // Reduces the size of the Secret to len bytes. The location of the
@@ -236,7 +249,72 @@ func (s Secret) Trim(len int) error {
 		return nil
 	}
 
-	return s.secret.realloc(C.size_t(len))
+	return s.secret.realloc(uintptr(len))
+}
+
+// Grows the Secret to len bytes, preserving its existing contents and
+// zero-filling the newly added bytes. The location of the overflow
+// canary is adjusted to reflect the new size of the Secret. If len is
+// smaller than or equal to the current length of the Secret, no
+// operation is performed.
+func (s Secret) Grow(len int) error {
+	// grow only grows; otherwise it's a no-op
+	if len <= s.Len() {
+		return nil
+	}
+
+	return s.secret.realloc(uintptr(len))
+}
+
+// Appends other's contents onto the end of the Secret, growing it to
+// accommodate them. other is left untouched.
+func (s Secret) Append(other *Secret) error {
+	offset := s.Len()
+
+	if err := s.Grow(offset + other.Len()); err != nil {
+		return err
+	}
+
+	s.Write()
+	defer s.Lock()
+
+	other.Read()
+	defer other.Lock()
+
+	copy(s.Slice()[offset:], other.Slice())
+
+	return nil
+}
+
+// Concatenates the contents of the given Secrets, in order, into a
+// newly allocated Secret. The originals are left untouched.
+func Concat(secrets ...*Secret) (*Secret, error) {
+	var size int
+
+	for _, s := range secrets {
+		size += s.Len()
+	}
+
+	result, err := NewSecret(size)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result.Write()
+	defer result.Lock()
+
+	var offset int
+
+	for _, s := range secrets {
+		s.Read()
+		copy(result.Slice()[offset:], s.Slice())
+		s.Lock()
+
+		offset += s.Len()
+	}
+
+	return result, nil
 }
 
 // Splits the Secret into two halves, with the right half beginning at
@@ -244,42 +322,42 @@ func (s Secret) Trim(len int) error {
 // contain the contents of the left half, and the contents of the
 // right half are copied into a new Secret which is returned.
 func (s Secret) Split(offset int) (*Secret, error) {
-	var (
-		right *Secret
-		err   error
-	)
-
 	s.ReadWrite()
-	defer s.Lock()
+	right, err := NewSecretFromBytes(s.Slice()[offset:])
+	s.Lock()
 
-	if right, err = NewSecretFromBytes(s.Slice()[offset:]); err != nil {
+	if err != nil {
 		return nil, err
 	}
 
-	s.Trim(offset)
+	// Trim reallocates s, which requires exclusive access; it's
+	// called only after the ReadWrite above has been released, rather
+	// than while s is still borrowed.
+	if err := s.Trim(offset); err != nil {
+		return nil, err
+	}
 
 	return right, nil
 }
 
 // Compares two Secrets for equality in constant time.
+//
+// Equal accesses both Secrets through Borrow rather than the legacy
+// Lock/Read API, so it nests correctly even if the same Secret is
+// passed on both sides, or already has a Borrow outstanding
+// elsewhere.
 func (s Secret) Equal(other *Secret) bool {
 	if s.Len() != other.Len() {
 		return false
 	}
 
-	s.Read()
-	defer s.Lock()
-
-	other.Read()
-	defer other.Lock()
+	r := s.Borrow()
+	defer r.Release()
 
-	ret := C.sodium_memcmp(
-		other.Pointer(),
-		s.Pointer(),
-		s.Size(),
-	)
+	ro := other.Borrow()
+	defer ro.Release()
 
-	return ret == 0
+	return allocator.ConstantTimeCompare(s.Pointer(), other.Pointer(), s.Size())
 }
 
 // Immediately zeroes out and releases the Secret's memory. Any
@@ -303,7 +381,22 @@ func (s Secret) Wipe() {
 // finalizer.
 type secret struct {
 	ptr  unsafe.Pointer
-	size C.size_t
+	size uintptr
+
+	// borrowMu guards state and serializes it with the mprotect
+	// call that backs each transition, so a concurrent borrow()
+	// can never observe an incremented state before the page
+	// permissions it implies have actually taken effect.
+	borrowMu sync.Mutex
+
+	// state tracks outstanding Borrows and BorrowMuts: 0 means
+	// unborrowed, a positive count means that many readers are
+	// borrowed, and borrowedMut means a single writer is
+	// borrowed. It is only read or changed while borrowMu is held:
+	// by borrow/unborrow and borrowMut/unborrowMut (which the legacy
+	// Read, Write, and ReadWrite methods also use), by unlockLegacy
+	// (behind the legacy Lock), and by realloc.
+	state int32
 }
 
 // Allocates enough memory to contain size bytes, plus room for a
@@ -311,7 +404,7 @@ type secret struct {
 // are locked into memory.
 //
 // The allocated memory is zeroed.
-func (s *secret) alloc(size C.size_t) error {
+func (s *secret) alloc(size uintptr) error {
 	var err error
 
 	// calculate the size of the user region, then allocate enough
@@ -327,15 +420,29 @@ func (s *secret) alloc(size C.size_t) error {
 	// allocated memory
 	runtime.SetFinalizer(s, func(s *secret) { s.free() })
 
-	s.unlock(C.PROT_WRITE)
+	s.unlock(memguard.Write)
 	defer s.lock()
 
-	C.sodium_memzero(s.ptr, s.size)
+	allocator.Zero(s.ptr, s.size)
 
 	return nil
 }
 
-func (s *secret) realloc(size C.size_t) error {
+// realloc resizes the secret's allocation, moving its contents if
+// necessary. It panics if a Borrow or BorrowMut is outstanding. Moving
+// the allocation out from under a live Borrow or BorrowMut would
+// leave it pointing at freed memory, so realloc requires exclusive
+// access the same way borrowMut does, and holds borrowMu for its
+// whole duration to rule out a Borrow or BorrowMut starting midway
+// through.
+func (s *secret) realloc(size uintptr) error {
+	s.borrowMu.Lock()
+	defer s.borrowMu.Unlock()
+
+	if s.state != 0 {
+		panic("secrets: can't resize a Secret with an outstanding Borrow or BorrowMut")
+	}
+
 	ptr, err := guardedRealloc(s.ptr, s.size, size)
 
 	if err != nil {
@@ -361,23 +468,58 @@ func (s *secret) free() {
 
 // Locks the secret's contents, preventing them from being read,
 // written to, or executed.
+//
+// This is a no-op once the secret has been freed; Wipe() zeroes out
+// ptr and size, and a Secret is commonly Wipe()d just before a
+// deferred Lock() fires.
 func (s *secret) lock() {
-	if ret, err := C.mprotect(s.ptr, s.size, C.PROT_NONE); ret != 0 {
+	if s.size == 0 {
+		return
+	}
+
+	if err := allocator.Protect(s.ptr, s.size, memguard.NoAccess); err != nil {
 		panic(err)
 	}
 }
 
 // Unlocks the secret's contents, giving them the protection level
 // specified.
-func (s *secret) unlock(prot C.int) {
-	if ret, err := C.mprotect(s.ptr, s.size, prot); ret != 0 {
+//
+// This is a no-op once the secret has been freed; see lock().
+func (s *secret) unlock(prot memguard.Prot) {
+	if s.size == 0 {
+		return
+	}
+
+	if err := allocator.Protect(s.ptr, s.size, prot); err != nil {
 		panic(err)
 	}
 }
 
+// unlockLegacy releases whichever access the legacy Read, Write, or
+// ReadWrite most recently granted, for use by the legacy Lock method.
+// It's a no-op if nothing is currently borrowed, so that a defensive
+// or redundant Lock() call behaves the same as it always has.
+func (s *secret) unlockLegacy() {
+	s.borrowMu.Lock()
+	defer s.borrowMu.Unlock()
+
+	switch {
+	case s.state == borrowedMut:
+		s.state = 0
+		s.lock()
+	case s.state > 0:
+		s.state--
+
+		if s.state == 0 {
+			s.lock()
+		}
+	}
+}
+
 // Calculates the size of an allocation with enough room for two extra
 // guard pages.
-func guardedAllocSize(size C.size_t) C.size_t {
+func guardedAllocSize(size uintptr) uintptr {
 	return 2*pageSize + _pageRound(size)
 }
 
@@ -386,23 +528,23 @@ func guardedAllocSize(size C.size_t) C.size_t {
 // returned points to a region inbetween the guard pages with enough
 // space to contain size bytes. An error is returned if the memory
 // can't be allocated or protected.
-func guardedAlloc(size C.size_t) (unsafe.Pointer, error) {
+func guardedAlloc(size uintptr) (unsafe.Pointer, error) {
 	var (
 		userSize  = size + canarySize
 		allocSize = guardedAllocSize(userSize)
 	)
 
-	allocPtr, err := C.mmap(nil, allocSize, C.PROT_NONE, C.MAP_ANON|C.MAP_PRIVATE, -1, 0)
+	allocPtr, err := allocator.Alloc(allocSize)
 
-	if int(uintptr(allocPtr)) == C._MAP_FAILED {
+	if err != nil {
 		return nil, err
 	}
 
 	userPtr := _ptrAdd(allocPtr, pageSize)
 
-	// we only need to mlock the user region; the guard pages can
+	// we only need to lock the user region; the guard pages can
 	// be swapped to disk if the OS wants to
-	if ret, err := C.sodium_mlock(userPtr, userSize); ret != 0 {
+	if err := allocator.Lock(userPtr, userSize); err != nil {
 		return nil, err
 	}
 
@@ -414,29 +556,65 @@ func guardedAlloc(size C.size_t) (unsafe.Pointer, error) {
 
 func guardedRealloc(
 	ptr unsafe.Pointer,
-	old C.size_t,
-	new C.size_t,
+	old uintptr,
+	new uintptr,
 ) (unsafe.Pointer, error) {
 	if old == new {
 		return ptr, nil
 	}
 
 	if old > new {
-		// TODO(stephen):
-		// - wipe the now-unused part of the secret
-
 		canaryVerify(ptr, old)
+
+		// wipe the now-unused tail of the secret before
+		// shrinking the canary's offset to cover it
+		if err := allocator.Protect(ptr, old, memguard.Write); err != nil {
+			return nil, err
+		}
+
+		allocator.Zero(_ptrAdd(ptr, new), old-new)
+
+		if err := allocator.Protect(ptr, old, memguard.NoAccess); err != nil {
+			return nil, err
+		}
+
 		canaryWrite(ptr, new)
 
 		return ptr, nil
 	}
 
-	panic("secrets: guardedRealloc only shrinks allocations")
+	// growing: allocate a fresh, larger guarded region, move the
+	// existing contents into it, and release the old region
+	canaryVerify(ptr, old)
+
+	newPtr, err := guardedAlloc(new)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := allocator.Protect(ptr, old, memguard.Read); err != nil {
+		return nil, err
+	}
+
+	if err := allocator.Protect(newPtr, new, memguard.Write); err != nil {
+		return nil, err
+	}
+
+	copy(unsafe.Slice((*byte)(newPtr), new), unsafe.Slice((*byte)(ptr), old))
+
+	if err := allocator.Protect(newPtr, new, memguard.NoAccess); err != nil {
+		return nil, err
+	}
+
+	guardedFree(ptr, old)
+
+	return newPtr, nil
 }
 
 // Frees an earlier allocation of the given number of bytes. Also
 // makes sure to free the surrounding pages.
-func guardedFree(ptr unsafe.Pointer, size C.size_t) {
+func guardedFree(ptr unsafe.Pointer, size uintptr) {
 	var (
 		allocSize = guardedAllocSize(size)
 		userSize  = size + canarySize
@@ -447,37 +625,44 @@ func guardedFree(ptr unsafe.Pointer, size C.size_t) {
 
 	canaryVerify(userPtr, size)
 
-	if ret, err := C.mprotect(userPtr, userSize, C.PROT_READ|C.PROT_WRITE); ret != 0 {
+	if err := allocator.Protect(userPtr, userSize, memguard.Read|memguard.Write); err != nil {
 		panic(err)
 	}
 
-	// wipe the user region (and canary, to avoid it from being leaked)
-	C.sodium_munlock(userPtr, userSize)
+	// wipe the user region (and canary, to avoid it from being
+	// leaked) before releasing it back to the operating system
+	allocator.Zero(userPtr, userSize)
+
+	if err := allocator.Unlock(userPtr, userSize); err != nil {
+		panic(err)
+	}
 
-	C.munmap(allocPtr, allocSize)
+	if err := allocator.Free(allocPtr, allocSize); err != nil {
+		panic(err)
+	}
 }
 
-func canaryWrite(ptr unsafe.Pointer, size C.size_t) {
+func canaryWrite(ptr unsafe.Pointer, size uintptr) {
 	var (
 		canaryPtr     = _ptrAdd(ptr, size)
 		canaryPagePtr = _ptrPageRound(canaryPtr)
 	)
 
 	// allow the user region to be written to, for the canary
-	if ret, _ := C.mprotect(canaryPagePtr, canarySize, C.PROT_WRITE); ret != 0 {
+	if err := allocator.Protect(canaryPagePtr, canarySize, memguard.Write); err != nil {
 		panic("secrets: couldn't write a canary")
 	}
 
 	// write the canary immediately after the user region
-	C.memcpy(canaryPtr, canary, canarySize)
+	copy(unsafe.Slice((*byte)(canaryPtr), canarySize), canary)
 
 	// re-lock the user region
-	if ret, _ := C.mprotect(canaryPagePtr, canarySize, C.PROT_NONE); ret != 0 {
+	if err := allocator.Protect(canaryPagePtr, canarySize, memguard.NoAccess); err != nil {
 		panic("secrets: couldn't write a canary")
 	}
 }
 
-func canaryVerify(ptr unsafe.Pointer, size C.size_t) {
+func canaryVerify(ptr unsafe.Pointer, size uintptr) {
 	var (
 		canaryPtr     = _ptrAdd(ptr, size)
 		canaryPagePtr = _ptrPageRound(canaryPtr)
@@ -485,35 +670,35 @@ func canaryVerify(ptr unsafe.Pointer, size C.size_t) {
 
 	// ensure the canary can be read and the user area can be
 	// wiped clean
-	if ret, err := C.mprotect(canaryPagePtr, canarySize, C.PROT_READ); ret != 0 {
+	if err := allocator.Protect(canaryPagePtr, canarySize, memguard.Read); err != nil {
 		panic(err)
 	}
 
 	// verify the canary
-	if C.memcmp(canaryPtr, canary, canarySize) != C.int(0) {
+	if !allocator.ConstantTimeCompare(canaryPtr, unsafe.Pointer(&canary[0]), canarySize) {
 		panic("secrets: buffer overflow canary triggered")
 	}
 }
 
 // Rounds the provided pointer to the beginning of its page.
 func _ptrPageRound(ptr unsafe.Pointer) unsafe.Pointer {
-	return _ptrAdd(ptr, -(C.size_t(uintptr(ptr)) % pageSize))
+	return _ptrAdd(ptr, -(uintptr(ptr) % pageSize))
 }
 
 // Rounds size to the next highest page boundary.
-func _pageRound(size C.size_t) C.size_t {
+func _pageRound(size uintptr) uintptr {
 	return (size/pageSize)*pageSize + pageSize
 }
 
 // Returns a pointer to the underlying buffer and the size of a byte slice.
-func _byteSlicePtrSize(slice []byte) (unsafe.Pointer, C.size_t) {
+func _byteSlicePtrSize(slice []byte) (unsafe.Pointer, uintptr) {
 	sh := (*reflect.SliceHeader)(unsafe.Pointer(&slice))
 
-	return unsafe.Pointer(sh.Data), C.size_t(sh.Len)
+	return unsafe.Pointer(sh.Data), uintptr(sh.Len)
 }
 
 // Performs pointer arithmetic, adding an offset (positive or
 // negative) to the provided pointer.
-func _ptrAdd(ptr unsafe.Pointer, offset C.size_t) unsafe.Pointer {
-	return unsafe.Pointer(uintptr(ptr) + uintptr(offset))
+func _ptrAdd(ptr unsafe.Pointer, offset uintptr) unsafe.Pointer {
+	return unsafe.Pointer(uintptr(ptr) + offset)
 }
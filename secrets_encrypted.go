@@ -0,0 +1,128 @@
+//go:build cgo
+
+package secrets
+
+// #include <sodium/crypto_secretbox.h>
+// #include <sodium/randombytes.h>
+// #include <sodium/utils.h>
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+)
+
+// ErrDecryption is returned by WithPlaintext when an EncryptedSecret's
+// ciphertext fails to authenticate. This can happen if the ciphertext
+// has been corrupted or tampered with.
+var ErrDecryption = errors.New("secrets: decryption failed")
+
+// An EncryptedSecret stores its contents encrypted in ordinary,
+// unguarded memory. Only the symmetric key used to encrypt it lives
+// inside a guarded Secret, so an application can hold many
+// EncryptedSecrets without exhausting the (typically small) per-
+// process mlock budget that guarded Secrets require.
+//
+// The plaintext is only ever materialized, inside a guarded Secret,
+// for the duration of a WithPlaintext call.
+//
+// EncryptedSecret encrypts with libsodium's crypto_secretbox and is
+// only available in cgo builds.
+type EncryptedSecret struct {
+	key        *Secret
+	nonce      [C.crypto_secretbox_NONCEBYTES]byte
+	ciphertext []byte
+}
+
+// NewEncryptedSecret encrypts data with a freshly generated key held
+// in a guarded Secret, then zeroes data. The returned EncryptedSecret
+// owns a copy of the ciphertext; it does not retain data.
+//
+// If the key's Secret can't be allocated, an error is returned.
+func NewEncryptedSecret(data []byte) (*EncryptedSecret, error) {
+	key, err := NewSecret(int(C.crypto_secretbox_KEYBYTES))
+
+	if err != nil {
+		return nil, err
+	}
+
+	es := &EncryptedSecret{key: key}
+
+	key.Write()
+	defer key.Lock()
+
+	C.randombytes_buf(key.Pointer(), C.size_t(key.Size()))
+	C.randombytes_buf(unsafe.Pointer(&es.nonce[0]), C.size_t(len(es.nonce)))
+
+	es.ciphertext = make([]byte, len(data)+int(C.crypto_secretbox_MACBYTES))
+
+	dataPtr, dataSize := _byteSlicePtrSize(data)
+
+	C.crypto_secretbox_easy(
+		(*C.uchar)(unsafe.Pointer(&es.ciphertext[0])),
+		(*C.uchar)(dataPtr),
+		C.ulonglong(dataSize),
+		(*C.uchar)(unsafe.Pointer(&es.nonce[0])),
+		(*C.uchar)(key.Pointer()),
+	)
+
+	C.sodium_memzero(dataPtr, dataSize)
+
+	return es, nil
+}
+
+// WithPlaintext transiently decrypts the EncryptedSecret's contents
+// into a guarded Secret, calls fn with it, and wipes the guarded
+// Secret before returning, regardless of whether fn succeeds.
+//
+// If the guarded Secret can't be allocated, or the ciphertext fails
+// to authenticate, an error is returned and fn is not called.
+func (es *EncryptedSecret) WithPlaintext(fn func(*Secret) error) error {
+	size := len(es.ciphertext) - int(C.crypto_secretbox_MACBYTES)
+
+	secret, err := NewSecret(size)
+
+	if err != nil {
+		return err
+	}
+
+	defer secret.Wipe()
+
+	secret.Write()
+	defer secret.Lock()
+
+	es.key.Read()
+	defer es.key.Lock()
+
+	ret := C.crypto_secretbox_open_easy(
+		(*C.uchar)(secret.Pointer()),
+		(*C.uchar)(unsafe.Pointer(&es.ciphertext[0])),
+		C.ulonglong(len(es.ciphertext)),
+		(*C.uchar)(unsafe.Pointer(&es.nonce[0])),
+		(*C.uchar)(es.key.Pointer()),
+	)
+
+	// release the Write before fn runs, so fn can Read or Borrow the
+	// plaintext Secret like any other; the deferred Lock above is
+	// then a no-op
+	secret.Lock()
+
+	if ret != 0 {
+		return ErrDecryption
+	}
+
+	return fn(secret)
+}
+
+// Wipe zeroes out and releases the EncryptedSecret's key, and clears
+// its ciphertext. Any attempt to reuse an EncryptedSecret after a
+// call to Wipe() will result in undefined behavior.
+func (es *EncryptedSecret) Wipe() {
+	es.key.Wipe()
+
+	for i := range es.ciphertext {
+		es.ciphertext[i] = 0
+	}
+
+	es.ciphertext = nil
+}
@@ -0,0 +1,62 @@
+//go:build cgo
+
+package secrets
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ExampleNewEncryptedSecret() {
+	es, err := NewEncryptedSecret([]byte("secret!"))
+
+	if err != nil {
+		return
+	}
+
+	es.WithPlaintext(func(s *Secret) error {
+		s.Read()
+		defer s.Lock()
+
+		fmt.Printf("%s", s.Slice())
+
+		return nil
+	})
+
+	es.Wipe()
+
+	// Output: secret!
+}
+
+func TestNewEncryptedSecretZeroesData(t *testing.T) {
+	data := []byte("secret!")
+
+	if _, err := NewEncryptedSecret(data); err != nil {
+		t.Fatalf("NewEncryptedSecret() = _, %v; want nil", err)
+	}
+
+	for i, b := range data {
+		if b != 0 {
+			t.Errorf("data[%d] = %d; want 0", i, b)
+		}
+	}
+}
+
+func TestEncryptedSecretTamperedCiphertext(t *testing.T) {
+	es, err := NewEncryptedSecret([]byte("secret!"))
+
+	if err != nil {
+		t.Fatalf("NewEncryptedSecret() = _, %v; want nil", err)
+	}
+
+	es.ciphertext[0] ^= 0xff
+
+	err = es.WithPlaintext(func(s *Secret) error {
+		t.Error("WithPlaintext() called fn with tampered ciphertext")
+		return nil
+	})
+
+	if err != ErrDecryption {
+		t.Errorf("WithPlaintext() = %v; want ErrDecryption", err)
+	}
+}
@@ -142,10 +142,13 @@ func TestNewSecret(t *testing.T) {
 	}
 
 	secret1.Write()
-	defer secret1.Lock()
-
 	copy(secret1.Slice(), "cryptographic secrets are secret")
+	secret1.Lock()
 
+	// Copy and Equal below access secret1 through Borrow, so the
+	// Write above must be released first; a Write left open would
+	// conflict with their Borrow the same way it would for any
+	// other caller.
 	secret2, err = secret1.Copy()
 
 	if err != nil {
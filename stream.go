@@ -0,0 +1,154 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"unsafe"
+
+	"github.com/stouset/go.secrets/memguard"
+)
+
+// ErrNoSpace is returned by the io.Writer returned from Secret.Writer
+// once the Secret's capacity has been exhausted.
+var ErrNoSpace = errors.New("secrets: not enough room in Secret")
+
+// Writer returns an io.Writer that writes sequentially into the
+// Secret's memory, starting at the beginning, so a caller can
+// `io.Copy(secret.Writer(), src)` from a socket or file without ever
+// materializing the Secret's contents in an intermediate, unprotected
+// buffer. Each Write borrows the Secret via BorrowMut for just long
+// enough to copy the written bytes in, so it coordinates correctly
+// with any other outstanding Borrow or BorrowMut on the same Secret.
+// It returns ErrNoSpace once the Secret's capacity is exhausted.
+func (s Secret) Writer() io.Writer {
+	return &secretWriter{secret: s}
+}
+
+// Reader returns an io.Reader that reads sequentially from the
+// Secret's memory, starting at the beginning. Each Read borrows the
+// Secret via Borrow for just long enough to copy the read bytes out.
+func (s Secret) Reader() io.Reader {
+	return &secretReader{secret: s}
+}
+
+type secretWriter struct {
+	secret Secret
+	offset int
+}
+
+func (w *secretWriter) Write(p []byte) (int, error) {
+	if w.offset >= w.secret.Len() {
+		return 0, ErrNoSpace
+	}
+
+	room := w.secret.Len() - w.offset
+	n := len(p)
+
+	if n > room {
+		n = room
+	}
+
+	withBorrowedWindow(w.secret, w.offset, n, memguard.Write, func(dst []byte) {
+		copy(dst, p[:n])
+	})
+
+	w.offset += n
+
+	if n < len(p) {
+		return n, ErrNoSpace
+	}
+
+	return n, nil
+}
+
+type secretReader struct {
+	secret Secret
+	offset int
+}
+
+func (r *secretReader) Read(p []byte) (int, error) {
+	if r.offset >= r.secret.Len() {
+		return 0, io.EOF
+	}
+
+	n := len(p)
+
+	if room := r.secret.Len() - r.offset; n > room {
+		n = room
+	}
+
+	withBorrowedWindow(r.secret, r.offset, n, memguard.Read, func(src []byte) {
+		copy(p[:n], src)
+	})
+
+	r.offset += n
+
+	return n, nil
+}
+
+// withBorrowedWindow borrows the Secret for the duration of fn,
+// through the same refcounted Borrow/BorrowMut machinery as
+// Secret.Borrow and Secret.BorrowMut, and calls fn with a slice
+// viewing exactly the n bytes starting at offset. Going through a
+// Borrow/BorrowMut (rather than calling mprotect on the window
+// directly) means it coordinates correctly with any other Borrow or
+// BorrowMut outstanding on the same Secret.
+func withBorrowedWindow(s Secret, offset, n int, prot memguard.Prot, fn func([]byte)) {
+	slice := unsafe.Slice((*byte)(_ptrAdd(s.Pointer(), uintptr(offset))), n)
+
+	if prot&memguard.Write != 0 {
+		b := s.BorrowMut()
+		defer b.Release()
+
+		fn(slice)
+		return
+	}
+
+	b := s.Borrow()
+	defer b.Release()
+
+	fn(slice)
+}
+
+// DecodeHex reads 2*size hex digits from r and decodes them directly
+// into a new, size-byte Secret, so the decoded bytes never exist in
+// an ordinary, unprotected Go byte slice.
+//
+// If allocation fails, or r doesn't yield enough valid hex digits, an
+// error is returned and no Secret is retained.
+func DecodeHex(r io.Reader, size int) (*Secret, error) {
+	return decode(hex.NewDecoder(r), size)
+}
+
+// DecodeBase64 reads standard-encoding base64 characters from r and
+// decodes them into a new, size-byte Secret. Whole three-byte groups
+// are decoded directly into the Secret; when size is not a multiple
+// of three, the final one or two bytes are first decoded into the
+// base64 decoder's own ordinary, unprotected buffer and copied from
+// there.
+//
+// If allocation fails, or r doesn't yield enough valid base64 data,
+// an error is returned and no Secret is retained.
+func DecodeBase64(r io.Reader, size int) (*Secret, error) {
+	return decode(base64.NewDecoder(base64.StdEncoding, r), size)
+}
+
+func decode(dec io.Reader, size int) (*Secret, error) {
+	secret, err := NewSecret(size)
+
+	if err != nil {
+		return nil, err
+	}
+
+	secret.Write()
+	defer secret.Lock()
+
+	if _, err := io.ReadFull(dec, secret.Slice()); err != nil {
+		secret.Wipe()
+		return nil, err
+	}
+
+	return secret, nil
+}
@@ -0,0 +1,114 @@
+package secrets
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func ExampleSecret_Writer() {
+	secret, err := NewSecret(7)
+
+	if err != nil {
+		return
+	}
+
+	io.Copy(secret.Writer(), bytes.NewBufferString("secret!"))
+
+	secret.Read()
+	defer secret.Lock()
+
+	fmt.Printf("%s", secret.Slice())
+
+	secret.Wipe()
+
+	// Output: secret!
+}
+
+func ExampleSecret_Reader() {
+	secret, _ := NewSecretFromBytes([]byte("secret!"))
+
+	var buf bytes.Buffer
+
+	io.Copy(&buf, secret.Reader())
+
+	fmt.Printf("%s", buf.String())
+
+	secret.Wipe()
+
+	// Output: secret!
+}
+
+func ExampleDecodeHex() {
+	secret, err := DecodeHex(bytes.NewBufferString("736563726574"), 6)
+
+	if err != nil {
+		return
+	}
+
+	secret.Read()
+	defer secret.Lock()
+
+	fmt.Printf("%s", secret.Slice())
+
+	secret.Wipe()
+
+	// Output: secret
+}
+
+func ExampleDecodeBase64() {
+	secret, err := DecodeBase64(bytes.NewBufferString("c2VjcmV0"), 6)
+
+	if err != nil {
+		return
+	}
+
+	secret.Read()
+	defer secret.Lock()
+
+	fmt.Printf("%s", secret.Slice())
+
+	secret.Wipe()
+
+	// Output: secret
+}
+
+func TestSecretWriterNoSpace(t *testing.T) {
+	secret, err := NewSecret(4)
+
+	if err != nil {
+		t.Fatalf("NewSecret(4) = _, %v; want nil", err)
+	}
+
+	n, err := secret.Writer().Write([]byte("secret!"))
+
+	if err != ErrNoSpace {
+		t.Errorf("Write() = _, %v; want ErrNoSpace", err)
+	}
+
+	if n != 4 {
+		t.Errorf("Write() = %d, _; want 4", n)
+	}
+}
+
+func TestSecretReaderEOF(t *testing.T) {
+	secret, err := NewSecretFromBytes([]byte("secret!"))
+
+	if err != nil {
+		t.Fatalf("NewSecretFromBytes() = _, %v; want nil", err)
+	}
+
+	buf := make([]byte, 16)
+	r := secret.Reader()
+
+	n, _ := io.ReadFull(r, buf)
+
+	if n != 7 {
+		t.Errorf("ReadFull() read %d bytes; want 7", n)
+	}
+
+	if _, err := r.Read(buf); err != io.EOF {
+		t.Errorf("Read() at end = _, %v; want io.EOF", err)
+	}
+}